@@ -7,15 +7,18 @@ package linux
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/spf13/viper"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"net"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
 	"golang.org/x/sys/unix"
@@ -43,10 +46,20 @@ var (
 	routeFilter = netlink.Route{
 		Table: unix.RT_TABLE_UNSPEC,
 	}
-	routeFilterMask            = netlink.RT_FILTER_TABLE
-	staticDevicesCheckInterval = 30 * time.Second
-	tcFilterParentIngress      = 0xfffffff2
-	tcFilterParentEgress       = 0xfffffff3
+	routeFilterMask = netlink.RT_FILTER_TABLE
+	// staticDevicesCheckInterval is now only a fallback safety net; device
+	// changes are normally detected via netlink event subscriptions and
+	// acted upon within debounceDeviceEvents.
+	staticDevicesCheckInterval = 5 * time.Minute
+	// debounceDeviceEvents coalesces bursts of netlink events (e.g. a NIC
+	// flapping or a bond renegotiating) into a single device set recompute.
+	debounceDeviceEvents  = 250 * time.Millisecond
+	tcFilterParentIngress = 0xfffffff2
+	tcFilterParentEgress  = 0xfffffff3
+
+	// maxDiagnosticEvents bounds the in-memory ring buffer of recent
+	// device-change events exposed by the diagnostic server.
+	maxDiagnosticEvents = 50
 )
 
 type DeviceManager struct {
@@ -55,6 +68,56 @@ type DeviceManager struct {
 	filter  deviceFilter
 	handle  *netlink.Handle
 	netns   netns.NsHandle
+
+	// reasons records why each device in devices was picked, e.g.
+	// "route", "k8s-node-ip", "user-specified" or "static-config". Read
+	// and written under Mutex, exposed read-only via the diagnostic
+	// server.
+	reasons map[string]string
+
+	// events is a bounded ring buffer of the last device-change events
+	// sent on a Listen() channel, newest last. Read and written under
+	// Mutex, exposed read-only via the diagnostic server.
+	events []deviceChangeEvent
+
+	// devicesChan and l3DevOK are set once by Listen() and read by the
+	// diagnostic server's redetect endpoint, so a manual redetect goes
+	// through the exact same resync/notify path as a netlink-triggered
+	// one instead of silently diverging dm.devices from what's been
+	// pushed to reloaders. Both are unset (devicesChan nil) until Listen
+	// has been called. Read and written under Mutex.
+	devicesChan chan []string
+	l3DevOK     bool
+}
+
+// deviceChangeEvent records a single point in time where the detected
+// device set changed, for the diagnostic server's /devices/events endpoint.
+type deviceChangeEvent struct {
+	Time time.Time `json:"time"`
+	// ChangedFamilies lists the address families (e.g. "ipv4", "ipv6")
+	// whose address change triggered this event, if that's what triggered
+	// it. Empty for events triggered by a device being added/removed or
+	// losing its tc filters, where Devices already carries the signal.
+	ChangedFamilies []string `json:"changedFamilies,omitempty"`
+	Devices         []string `json:"devices"`
+}
+
+// recordReason remembers why a device was selected, for diagnostics. Must
+// be called with dm.Mutex held.
+func (dm *DeviceManager) recordReason(name, reason string) {
+	if dm.reasons == nil {
+		dm.reasons = make(map[string]string)
+	}
+	dm.reasons[name] = reason
+}
+
+// recordEvent appends a device-change event to the bounded event ring
+// buffer. Must be called with dm.Mutex held.
+func (dm *DeviceManager) recordEvent(devices []string, changedFamilies []string) {
+	dm.events = append(dm.events, deviceChangeEvent{Time: time.Now(), Devices: devices, ChangedFamilies: changedFamilies})
+	if len(dm.events) > maxDiagnosticEvents {
+		dm.events = dm.events[len(dm.events)-maxDiagnosticEvents:]
+	}
 }
 
 func NewDeviceManager() (*DeviceManager, error) {
@@ -118,18 +181,21 @@ func (dm *DeviceManager) Detect() ([]string, error) {
 	} else {
 		for _, dev := range option.Config.GetDevices() {
 			dm.devices[dev] = struct{}{}
+			dm.recordReason(dev, "user-specified")
 		}
 	}
 
 	detectDirectRoutingDev := option.Config.DirectRoutingDeviceRequired()
 	if option.Config.DirectRoutingDeviceRequired() && option.Config.DirectRoutingDevice != "" {
 		dm.devices[option.Config.DirectRoutingDevice] = struct{}{}
+		dm.recordReason(option.Config.DirectRoutingDevice, "direct-routing-device")
 		detectDirectRoutingDev = false
 	}
 
 	detectIPv6MCastDev := option.Config.EnableIPv6NDP
 	if option.Config.IPv6MCastDevice != "" {
 		dm.devices[option.Config.IPv6MCastDevice] = struct{}{}
+		dm.recordReason(option.Config.IPv6MCastDevice, "ipv6-mcast-device")
 		detectIPv6MCastDev = false
 	}
 
@@ -139,6 +205,7 @@ func (dm *DeviceManager) Detect() ([]string, error) {
 		if err == nil {
 			k8sNodeDev = k8sNodeLink.Attrs().Name
 			dm.devices[k8sNodeDev] = struct{}{}
+			dm.recordReason(k8sNodeDev, "k8s-node-ip")
 		} else if k8s.IsEnabled() {
 			return nil, fmt.Errorf("k8s is enabled, but still failed to find node IP: %w", err)
 		}
@@ -189,36 +256,55 @@ func (dm *DeviceManager) getDeviceList() []string {
 // Exclude devices that have one or more of these flags set.
 var excludedIfFlagsMask uint32 = unix.IFF_SLAVE | unix.IFF_LOOPBACK
 
-// isViableDevice returns true if the given link is usable and Cilium should attach
-// programs to it.
-func (dm *DeviceManager) isViableDevice(l3DevOK, hasDefaultRoute bool, link netlink.Link) bool {
+// isViableDevice returns the name of the device Cilium should attach
+// programs to for the given link, and whether it is usable at all. This is
+// usually just the link's own name, but for a bond/team slave it is the
+// name of the bond/team master, since the slave itself is never tracked as
+// a device - see masterOf. Which links of a bond/team are probed for BPF
+// programs is decided separately by devicesToProbe.
+func (dm *DeviceManager) isViableDevice(l3DevOK, hasDefaultRoute bool, link netlink.Link) (string, bool) {
 	name := link.Attrs().Name
 
+	// If this is a slave of a bond/team, the actual node NIC Cilium should
+	// attach to is the bond/team master, so resolve it before the
+	// IFF_SLAVE check below would otherwise reject it outright. Per-slave
+	// attachment (e.g. for XDP) is handled separately by resolveBondSlaves.
+	if master, ok := dm.masterOf(link); ok {
+		switch master.Type() {
+		case "bond", "team":
+			return dm.isViableDevice(l3DevOK, hasDefaultRoute, master)
+
+		case "bridge", "openvswitch":
+			log.WithField(logfields.Device, name).Debug("Ignoring device attached to bridge")
+			return "", false
+		}
+	}
+
 	// Do not consider any of the excluded devices.
 	for _, p := range excludedDevicePrefixes {
 		if strings.HasPrefix(name, p) {
 			log.WithField(logfields.Device, name).
 				Debugf("Skipping device as it has excluded prefix '%s'", p)
-			return false
+			return "", false
 		}
 	}
 
 	// Skip devices that have an excluded interface flag set.
 	if link.Attrs().RawFlags&excludedIfFlagsMask != 0 {
 		log.WithField(logfields.Device, name).Debugf("Skipping device as it has excluded flag (%x)", link.Attrs().RawFlags)
-		return false
+		return "", false
 	}
 
 	// Ignore L3 devices if we cannot support them.
 	if !l3DevOK && !mac.LinkHasMacAddr(link) {
 		log.WithField(logfields.Device, name).
 			Info("Ignoring L3 device; >= 5.8 kernel is required.")
-		return false
+		return "", false
 	}
 
 	// If user specified devices or wildcards, then skip the device if it doesn't match.
-	if !dm.filter.match(name) {
-		return false
+	if !dm.filter.match(name, dm.handle) {
+		return "", false
 	}
 
 	switch link.Type() {
@@ -229,7 +315,7 @@ func (dm *DeviceManager) isViableDevice(l3DevOK, hasDefaultRoute bool, link netl
 		if !hasDefaultRoute {
 			log.WithField(logfields.Device, name).
 				Debug("Ignoring veth device as it has no default route")
-			return false
+			return "", false
 		}
 
 	case "bridge", "openvswitch":
@@ -237,26 +323,80 @@ func (dm *DeviceManager) isViableDevice(l3DevOK, hasDefaultRoute bool, link netl
 		// purposes. In the rare cases where a user wants to load datapath
 		// programs onto them they can override device detection with --devices.
 		log.WithField(logfields.Device, name).Debug("Ignoring bridge-like device")
-		return false
+		return "", false
 
 	}
 
-	if link.Attrs().MasterIndex > 0 {
-		if master, err := dm.handle.LinkByIndex(link.Attrs().MasterIndex); err == nil {
-			switch master.Type() {
-			case "bridge", "openvswitch":
-				log.WithField(logfields.Device, name).Debug("Ignoring device attached to bridge")
-				return false
+	return name, true
+}
 
-			case "bond", "team":
-				log.WithField(logfields.Device, name).Debug("Ignoring bonded device")
-				return false
-			}
+// masterOf returns the master link of the given link and true, or
+// (nil, false) if the link has no master.
+func (dm *DeviceManager) masterOf(link netlink.Link) (netlink.Link, bool) {
+	if link.Attrs().MasterIndex <= 0 {
+		return nil, false
+	}
+	master, err := dm.handle.LinkByIndex(link.Attrs().MasterIndex)
+	if err != nil {
+		return nil, false
+	}
+	return master, true
+}
 
+// resolveBondMaster returns the bond/team master link of a slave device, or
+// nil if the device is not a bond/team slave.
+func (dm *DeviceManager) resolveBondMaster(link netlink.Link) netlink.Link {
+	if master, ok := dm.masterOf(link); ok {
+		switch master.Type() {
+		case "bond", "team":
+			return master
 		}
 	}
+	return nil
+}
 
-	return true
+// resolveBondSlaves returns the slave links of a bond/team master. Callers
+// that must attach BPF programs per physical NIC rather than to the
+// bond/team master (e.g. XDP, which cannot be loaded on a bond/team device)
+// use this to find the concrete links to attach to.
+func (dm *DeviceManager) resolveBondSlaves(master netlink.Link) ([]netlink.Link, error) {
+	allLinks, err := dm.handle.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links to resolve bond slaves of %s: %w", master.Attrs().Name, err)
+	}
+	slaves := make([]netlink.Link, 0, len(allLinks))
+	for _, link := range allLinks {
+		if link.Attrs().MasterIndex == master.Attrs().Index {
+			slaves = append(slaves, link)
+		}
+	}
+	return slaves, nil
+}
+
+// devicesToProbe returns the concrete links that BPF programs are expected
+// to be attached to for a tracked device name, honoring
+// option.Config.BondSlaveAttach for bond/team masters.
+func (dm *DeviceManager) devicesToProbe(name string) ([]netlink.Link, error) {
+	link, err := dm.handle.LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch link.Type() {
+	case "bond", "team":
+		switch option.Config.BondSlaveAttach {
+		case option.BondSlaveAttachSlaves:
+			return dm.resolveBondSlaves(link)
+		case option.BondSlaveAttachBoth:
+			slaves, err := dm.resolveBondSlaves(link)
+			if err != nil {
+				return nil, err
+			}
+			return append([]netlink.Link{link}, slaves...), nil
+		}
+	}
+
+	return []netlink.Link{link}, nil
 }
 
 type linkInfo struct {
@@ -285,7 +425,6 @@ func (dm *DeviceManager) updateDevicesFromRoutes(l3DevOK bool, routes []netlink.
 
 	changed := false
 	for index, info := range linkInfos {
-		// TODO: 当反复重启网络设备时，此处可能使变更信息丢失
 		link, err := dm.handle.LinkByIndex(index)
 		if err != nil {
 			log.WithError(err).WithField(logfields.LinkIndex, index).
@@ -299,76 +438,201 @@ func (dm *DeviceManager) updateDevicesFromRoutes(l3DevOK bool, routes []netlink.
 			continue
 		}
 
-		viable := dm.isViableDevice(l3DevOK, info.hasDefaultRoute, link)
-		if viable {
-			dm.devices[name] = struct{}{}
-			changed = true
-		} else {
+		deviceName, viable := dm.isViableDevice(l3DevOK, info.hasDefaultRoute, link)
+		if !viable {
 			log.WithField(logfields.Device, name).Debug("Skipping unviable device")
+			continue
+		}
+		if _, exists := dm.devices[deviceName]; !exists {
+			dm.devices[deviceName] = struct{}{}
+			dm.recordReason(deviceName, "route")
+			changed = true
 		}
 	}
 	return changed
 }
 
+// resync recomputes the full device set from the current state of the host:
+// devices discovered from routes (the same logic updateDevicesFromRoutes
+// applies to a route batch) plus the static/dynamic device bookkeeping that
+// checkStaticDevices performs (lost config, lost tc filters, removed links,
+// address changes). It is the single place Listen calls into, whether it
+// was woken up by a netlink event, the fallback ticker, or a subscription
+// resync. Returns true if the device set changed, along with the address
+// families (if any) whose change triggered it. Must be called with dm.Mutex
+// held.
+func (dm *DeviceManager) resync(l3DevOK bool) (bool, []string) {
+	changed := false
+
+	if len(option.Config.GetDevices()) == 0 && dm.AreDevicesRequired() {
+		family := netlink.FAMILY_ALL
+		if option.Config.EnableIPv4 && !option.Config.EnableIPv6 {
+			family = netlink.FAMILY_V4
+		} else if !option.Config.EnableIPv4 && option.Config.EnableIPv6 {
+			family = netlink.FAMILY_V6
+		}
+		routes, err := dm.handle.RouteListFiltered(family, &routeFilter, routeFilterMask)
+		if err != nil {
+			log.WithError(err).Warn("resync: failed to list routes, skipping route-based detection")
+		} else if dm.updateDevicesFromRoutes(l3DevOK, routes) {
+			changed = true
+		}
+	}
+
+	staticChanged, changedFamilies := dm.checkStaticDevices()
+	if staticChanged {
+		changed = true
+	}
+
+	return changed, changedFamilies
+}
+
 // Listen starts listening to changes to network devices. When devices change the new set
 // of devices is sent on the returned channel.
+//
+// Rather than polling on a fixed interval, Listen subscribes to netlink link,
+// address and route events so that device additions/removals and address
+// changes are picked up within debounceDeviceEvents of happening. No tc
+// notifications are subscribed to, so a lost TC filter is only noticed on
+// the next recompute, whether triggered by another netlink event or by the
+// periodic check. That check runs at staticDevicesCheckInterval and also
+// covers a subscription that silently misses events.
 func (dm *DeviceManager) Listen(ctx context.Context) (chan []string, error) {
-
 	devicesChan := make(chan []string, 1)
 
-	// Find links deleted after Detect()
-	if allLinks, err := dm.handle.LinkList(); err == nil {
-		changed := false
-		linksByName := map[string]struct{}{}
-		for _, link := range allLinks {
-			linksByName[link.Attrs().Name] = struct{}{}
+	l3DevOK := true
+	if !option.Config.EnableHostLegacyRouting {
+		l3DevOK = supportL3Dev()
+	}
+
+	dm.Lock()
+	dm.devicesChan = devicesChan
+	dm.l3DevOK = l3DevOK
+	dm.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(done)
+	}()
+
+	// resyncTrigger is signalled whenever a subscription hits an error and
+	// needs ListExisting-style full resync, in addition to whatever partial
+	// event woke us up.
+	resyncTrigger := make(chan struct{}, 1)
+	errorCallback := func(err error) {
+		log.WithError(err).Warn("netlink subscription error, scheduling a resync")
+		select {
+		case resyncTrigger <- struct{}{}:
+		default:
 		}
-		dm.Lock()
-		for name := range dm.devices {
-			if _, exists := linksByName[name]; !exists {
-				delete(dm.devices, name)
-				changed = true
+	}
+
+	linkUpdates := make(chan netlink.LinkUpdate, 64)
+	if err := netlink.LinkSubscribeWithOptions(linkUpdates, done, netlink.LinkSubscribeOptions{
+		ListExisting:  true,
+		ErrorCallback: errorCallback,
+		Namespace:     &dm.netns,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to subscribe to link updates: %w", err)
+	}
+
+	addrUpdates := make(chan netlink.AddrUpdate, 64)
+	if err := netlink.AddrSubscribeWithOptions(addrUpdates, done, netlink.AddrSubscribeOptions{
+		ListExisting:  true,
+		ErrorCallback: errorCallback,
+		Namespace:     &dm.netns,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to subscribe to address updates: %w", err)
+	}
+
+	routeUpdates := make(chan netlink.RouteUpdate, 64)
+	if err := netlink.RouteSubscribeWithOptions(routeUpdates, done, netlink.RouteSubscribeOptions{
+		ListExisting:  true,
+		ErrorCallback: errorCallback,
+		Namespace:     &dm.netns,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to subscribe to route updates: %w", err)
+	}
+
+	if option.Config.EnableDeviceManagerDiagnostics {
+		go func() {
+			if err := dm.ServeDiagnostics(ctx); err != nil {
+				log.WithError(err).Error("device manager diagnostic server stopped")
 			}
-		}
-		devices := dm.getDeviceList()
-		dm.Unlock()
+		}()
+	}
 
-		if changed {
-			log.WithField(logfields.Devices, devices).Info("Devices changed")
-			devicesChan <- devices
-		}
+	dynamicDeviceUpdates, err := dm.watchDynamicDevicesDir(done)
+	if err != nil {
+		log.WithError(err).Warn("dynamic devices directory watch disabled, falling back to periodic check only")
 	}
 
 	go func() {
-		log.Info("Listening for device changes")
+		log.Info("Listening for device changes via netlink subscriptions")
 
-		log.WithField("interval", staticDevicesCheckInterval).Info("Start static devices check")
+		log.WithField("interval", staticDevicesCheckInterval).Info("Start static devices fallback check")
 		ticker := time.NewTicker(staticDevicesCheckInterval)
-		ticker.Reset(staticDevicesCheckInterval)
+		defer ticker.Stop()
 
-		for {
-			devicesChanged := false
-			var devices []string
+		var debounceTimer *time.Timer
+		var debounceC <-chan time.Time
+		pending := false
+
+		scheduleRecompute := func() {
+			pending = true
+			if debounceTimer == nil {
+				debounceTimer = time.NewTimer(debounceDeviceEvents)
+			} else {
+				if !debounceTimer.Stop() {
+					select {
+					case <-debounceTimer.C:
+					default:
+					}
+				}
+				debounceTimer.Reset(debounceDeviceEvents)
+			}
+			debounceC = debounceTimer.C
+		}
 
+		for {
 			select {
 			case <-ctx.Done():
 				log.Debug("context closed, Listen() stopping")
-				ticker.Stop()
 				return
+
+			case <-linkUpdates:
+				scheduleRecompute()
+			case <-addrUpdates:
+				scheduleRecompute()
+			case <-routeUpdates:
+				scheduleRecompute()
+			case <-dynamicDeviceUpdates:
+				scheduleRecompute()
+			case <-resyncTrigger:
+				scheduleRecompute()
 			case <-ticker.C:
-				// check device is ok and recover
+				scheduleRecompute()
+
+			case <-debounceC:
+				debounceC = nil
+				if !pending {
+					continue
+				}
+				pending = false
+
 				dm.Lock()
-				devicesChanged = dm.checkStaticDevices() // 检查 --devices 指定的设备是否配置丢失，或者 tc filter 丢失
-				devices = dm.getDeviceList()
-				if devicesChanged {
-					log.WithField(logfields.Devices, devices).Info("Ticker check for static devices")
+				changed, changedFamilies := dm.resync(l3DevOK)
+				devices := dm.getDeviceList()
+				if changed {
+					dm.recordEvent(devices, changedFamilies)
 				}
 				dm.Unlock()
-			}
 
-			if devicesChanged {
-				log.WithField(logfields.Devices, devices).Info("Devices changed")
-				devicesChan <- devices
+				if changed {
+					log.WithField(logfields.Devices, devices).Info("Devices changed")
+					devicesChan <- devices
+				}
 			}
 		}
 	}()
@@ -416,8 +680,18 @@ func (dm *DeviceManager) expandDeviceWildcards(devices []string, option string)
 			prefix := strings.TrimRight(iface, "+")
 			for _, link := range allLinks {
 				attrs := link.Attrs()
-				if strings.HasPrefix(attrs.Name, prefix) && checkDeviceWithIP(attrs.Name) {
-					expandedDevicesMap[attrs.Name] = struct{}{}
+				if !strings.HasPrefix(attrs.Name, prefix) {
+					continue
+				}
+				// A bond/team slave matching the wildcard is never itself
+				// tracked as a device; resolve it to its master so e.g. "eth+" still
+				// picks up a bonded "bond0" uplink.
+				name := attrs.Name
+				if master := dm.resolveBondMaster(link); master != nil {
+					name = master.Attrs().Name
+				}
+				if checkDeviceWithIP(name, dm.handle) {
+					expandedDevicesMap[name] = struct{}{}
 				}
 			}
 		} else {
@@ -439,7 +713,63 @@ func (dm *DeviceManager) expandDeviceWildcards(devices []string, option string)
 	return expandedDevices, nil
 }
 
-func (dm *DeviceManager) checkStaticDevices() bool {
+// staticAndDynamicDeviceFilter returns the combined device filter built from
+// the static --devices configuration and the dynamic devices directory.
+// Shared by checkStaticDevices and the diagnostic server so both report the
+// same view of "what is configured".
+//
+// As a side effect, it honors the direct-routing=true and ipv6-mcast=true
+// attributes on dynamic device entries by filling in
+// option.Config.DirectRoutingDevice / IPv6MCastDevice if they are not
+// already set, so operators no longer have to pass those flags separately
+// when the device is already named in the dynamic-devices directory.
+func (dm *DeviceManager) staticAndDynamicDeviceFilter() deviceFilter {
+	allDeviceConfigs := sets.NewString(viper.GetStringSlice(option.Devices)...)
+
+	dynamicDeviceConfigs, err := ReadDynamicDevices()
+	if err != nil {
+		log.WithError(err).Error("read dynamic-device config failed, skip")
+	}
+
+	for _, cfg := range dynamicDeviceConfigs {
+		name := cfg.Name
+		if cfg.BondMaster {
+			if link, err := dm.handle.LinkByName(cfg.Name); err == nil {
+				if master := dm.resolveBondMaster(link); master != nil {
+					name = master.Attrs().Name
+				}
+			}
+		}
+
+		switch {
+		case cfg.Exclude:
+			allDeviceConfigs.Insert("!" + name)
+		case cfg.Wildcard:
+			allDeviceConfigs.Insert(name + "+")
+		default:
+			allDeviceConfigs.Insert(name)
+		}
+
+		if cfg.DirectRouting && option.Config.DirectRoutingDevice == "" {
+			option.Config.DirectRoutingDevice = name
+			log.WithField("device", name).Info("Direct routing device set from dynamic-devices config")
+		}
+		if cfg.IPv6MCast && option.Config.IPv6MCastDevice == "" {
+			option.Config.IPv6MCastDevice = name
+			log.WithField("device", name).Info("IPv6 multicast device set from dynamic-devices config")
+		}
+	}
+
+	return deviceFilter(allDeviceConfigs.List())
+}
+
+// checkStaticDevices reports whether the detected device set or any
+// device's address changed. The second return value lists the address
+// families (e.g. "ipv4", "ipv6") for which an address change was the
+// trigger, so the recorded deviceChangeEvent can tell that apart from a
+// device being added, removed, or losing its tc filters. The channel
+// returned by Listen carries only the device list, not these families.
+func (dm *DeviceManager) checkStaticDevices() (bool, []string) {
 
 	// 检查以下内容：
 	//  1. 通过 --devices 配置的设备是否配置丢失
@@ -448,25 +778,16 @@ func (dm *DeviceManager) checkStaticDevices() bool {
 	allLinks, err := dm.handle.LinkList()
 	if err != nil {
 		log.WithError(err).Error("checkStaticDevices failed, skip")
-		return false
+		return false, nil
 	}
 
 	changed := false
+	var changedFamilies []string
 
-	allDeviceConfigs := sets.NewString(viper.GetStringSlice(option.Devices)...)
-
-	dynamicDeviceConfigs, err := ReadDynamicDevices()
-	if err != nil {
-		log.WithError(err).Error("read dynamic-device config failed, skip")
-	}
-	if dynamicDeviceConfigs != nil {
-		allDeviceConfigs.Insert(dynamicDeviceConfigs...)
-	}
-
-	filter := deviceFilter(allDeviceConfigs.List())
+	filter := dm.staticAndDynamicDeviceFilter()
 
 	if len(filter) == 0 {
-		return false
+		return false, nil
 	}
 
 	currentExistOnHost := make(map[string]netlink.Link)
@@ -481,7 +802,7 @@ func (dm *DeviceManager) checkStaticDevices() bool {
 			}
 		}
 
-		if !filter.match(name) || isExcluded {
+		if !filter.match(name, dm.handle) || isExcluded {
 			if _, alreadyConfig := dm.devices[name]; alreadyConfig {
 				delete(dm.devices, name)
 				changed = true
@@ -500,17 +821,34 @@ func (dm *DeviceManager) checkStaticDevices() bool {
 				Info("Static device config lost, load again")
 
 			dm.devices[name] = struct{}{}
+			dm.recordReason(name, "static-config")
 			changed = true
 			continue
 		}
 
 		// tc filter 丢失
-		if exists && dm.tcFiltersLost(link) {
-			log.WithField("device", name).
-				WithField("method", "checkStaticDevices").
-				Info("Static device tc filter lost, load again")
-			changed = true
-			continue
+		if exists {
+			probeLinks, err := dm.devicesToProbe(name)
+			if err != nil {
+				log.WithError(err).WithField("device", name).
+					WithField("method", "checkStaticDevices").
+					Warning("Can't resolve links to probe for tc filters, falling back to the device itself")
+				probeLinks = []netlink.Link{link}
+			}
+			filtersLost := false
+			for _, probeLink := range probeLinks {
+				if dm.tcFiltersLost(probeLink) {
+					filtersLost = true
+					break
+				}
+			}
+			if filtersLost {
+				log.WithField("device", name).
+					WithField("method", "checkStaticDevices").
+					Info("Static device tc filter lost, load again")
+				changed = true
+				continue
+			}
 		}
 
 	}
@@ -527,57 +865,80 @@ func (dm *DeviceManager) checkStaticDevices() bool {
 		}
 	}
 
-	// 判断 IP地址是否变化
-	addrWithDevices := node.GetMasqIPv4AddrsWithDevices()
+	// 判断 IP地址是否变化（同时考虑 IPv4 与 IPv6）
+	addrFamilies := []struct {
+		name        string
+		family      int
+		oldByDevice map[string]net.IP
+	}{
+		{"ipv4", netlink.FAMILY_V4, node.GetMasqIPv4AddrsWithDevices()},
+		{"ipv6", netlink.FAMILY_V6, node.GetMasqIPv6AddrsWithDevices()},
+	}
+
 	for name := range dm.devices {
-		oldAddr, ipExists := addrWithDevices[name]
-		if !ipExists {
-			log.WithField("device", name).
-				WithField("method", "checkStaticDevices").
-				Warning("Can't get old address, skip")
+		link, exists := currentExistOnHost[name]
+		if !exists {
 			continue
 		}
 
-		// TODO: 暂时只考虑 IPV4
-		addrs, listV4err := netlink.AddrList(currentExistOnHost[name], netlink.FAMILY_V4)
-		if listV4err != nil {
-			log.WithField("device", name).
-				WithField("method", "checkStaticDevices").
-				Warning("Can't list address")
-			continue
-		}
-		addrChange := true
-		for _, addr := range addrs {
-			// 考虑主 IP 变化的情况
-			if (addr.Flags & (unix.IFA_F_SECONDARY | unix.IFA_F_DEPRECATED)) != 0 {
+		for _, fam := range addrFamilies {
+			oldAddr, ipExists := fam.oldByDevice[name]
+			if !ipExists {
+				// No baseline recorded for this family on this device, e.g.
+				// the device is IPv4-only. Nothing to compare against.
 				continue
 			}
-			if oldAddr.Equal(addr.IP) {
-				addrChange = false
-				break
+
+			addrs, err := netlink.AddrList(link, fam.family)
+			if err != nil {
+				log.WithField("device", name).
+					WithField("family", fam.name).
+					WithField("method", "checkStaticDevices").
+					Warning("Can't list address")
+				continue
 			}
-		}
-		if addrChange {
-			log.WithField("device", name).
-				WithField("old", oldAddr).WithField("current", addrs).
-				WithField("method", "checkStaticDevices").
-				Warning("Address changed")
-			changed = true
-		}
 
+			// A device may carry several primary global addresses at once,
+			// e.g. right after a DHCP/RA renewal adds a new one before the
+			// old one is removed, so track the whole current set rather
+			// than comparing against a single address.
+			current := sets.NewString()
+			for _, addr := range addrs {
+				// 考虑主 IP 变化的情况，忽略 secondary/deprecated/tentative 以及 link-local 地址
+				if (addr.Flags & (unix.IFA_F_SECONDARY | unix.IFA_F_DEPRECATED | unix.IFA_F_TENTATIVE)) != 0 {
+					continue
+				}
+				if addr.IP.IsLinkLocalUnicast() {
+					continue
+				}
+				current.Insert(addr.IP.String())
+			}
+
+			if !current.Has(oldAddr.String()) {
+				log.WithField("device", name).
+					WithField("family", fam.name).
+					WithField("old", oldAddr).WithField("current", current.List()).
+					WithField("method", "checkStaticDevices").
+					Warning("Address changed")
+				changed = true
+				changedFamilies = append(changedFamilies, fam.name)
+			}
+		}
 	}
 
-	return changed
+	return changed, changedFamilies
 }
 
-func (dm *DeviceManager) tcFiltersLost(link netlink.Link) bool {
+// tcFilters returns the cilium-managed BPF TC filters currently attached to
+// link, probing both the ingress and egress parents. Used directly by the
+// diagnostic server, and via tcFiltersLost by checkStaticDevices.
+func (dm *DeviceManager) tcFilters(link netlink.Link) ([]*netlink.BpfFilter, error) {
 	allFilters := []*netlink.BpfFilter{}
 
 	for _, parent := range []uint32{uint32(tcFilterParentIngress), uint32(tcFilterParentEgress)} {
 		filters, err := netlink.FilterList(link, parent)
 		if err != nil {
-			log.WithError(err).WithField("device", link.Attrs().Name).Error("Check TC Filter lost")
-			return false
+			return nil, fmt.Errorf("listing tc filters on %s: %w", link.Attrs().Name, err)
 		}
 		for _, f := range filters {
 			if bpfFilter, ok := f.(*netlink.BpfFilter); ok {
@@ -592,6 +953,16 @@ func (dm *DeviceManager) tcFiltersLost(link netlink.Link) bool {
 		}
 	}
 
+	return allFilters, nil
+}
+
+func (dm *DeviceManager) tcFiltersLost(link netlink.Link) bool {
+	allFilters, err := dm.tcFilters(link)
+	if err != nil {
+		log.WithError(err).WithField("device", link.Attrs().Name).Error("Check TC Filter lost")
+		return false
+	}
+
 	return len(allFilters) == 0
 }
 
@@ -643,14 +1014,41 @@ func supportL3Dev() bool {
 
 type deviceFilter []string
 
-func (lst deviceFilter) match(dev string) bool {
+func (lst deviceFilter) match(dev string, handle *netlink.Handle) bool {
 	if len(lst) == 0 {
 		return true
 	}
+
+	hasPositiveEntry := false
+
+	// Explicit excludes (from the dynamic devices directory's "!name"
+	// syntax) always win over a wildcard or exact match below.
+	for _, entry := range lst {
+		if strings.HasPrefix(entry, "!") {
+			if dev == strings.TrimPrefix(entry, "!") {
+				return false
+			}
+			continue
+		}
+		hasPositiveEntry = true
+	}
+
+	// A filter made up of nothing but excludes (e.g. a dynamic-devices
+	// file containing only "!eth1") means "everything except the
+	// excluded ones", not "nothing": without this, every device not
+	// explicitly excluded would still fall through to false below and
+	// checkStaticDevices would unconfigure every already-tracked device.
+	if !hasPositiveEntry {
+		return true
+	}
+
 	for _, entry := range lst {
+		if strings.HasPrefix(entry, "!") {
+			continue
+		}
 		if strings.HasSuffix(entry, "+") {
 			prefix := strings.TrimRight(entry, "+")
-			if strings.HasPrefix(dev, prefix) && checkDeviceWithIP(dev) {
+			if strings.HasPrefix(dev, prefix) && checkDeviceWithIP(dev, handle) {
 				return true
 			}
 			continue
@@ -662,9 +1060,14 @@ func (lst deviceFilter) match(dev string) bool {
 	return false
 }
 
-func checkDeviceWithIP(dev string) bool {
+// checkDeviceWithIP resolves dev through handle rather than the package-level
+// netlink.LinkByName, so callers bound to a non-default network namespace
+// (e.g. DeviceManager opened via NewDeviceManagerAt) still see it -- this
+// matters for the bond-master substitution in expandDeviceWildcards, which
+// calls this on a name resolved from dm.handle's own LinkList.
+func checkDeviceWithIP(dev string, handle *netlink.Handle) bool {
 
-	l, err := netlink.LinkByName(dev)
+	l, err := handle.LinkByName(dev)
 	if err != nil {
 		log.WithField("device", dev).
 			WithField("method", "checkDeviceWithIP").
@@ -703,14 +1106,107 @@ func checkLinkAddrs(l netlink.Link, family int) bool {
 
 const DynamicDevicePath = "/etc/dynamic-devices"
 
-func ReadDynamicDevices() ([]string, error) {
+// DynamicDeviceConfig is one parsed entry from a file under
+// DynamicDevicePath: a device name (exact, or a "+"-suffixed prefix
+// wildcard), an optional exclude marker, and optional attributes that let
+// the dynamic-devices directory take over jobs that would otherwise need
+// --direct-routing-device or --ipv6-mcast-device.
+type DynamicDeviceConfig struct {
+	Name          string `json:"name"`
+	Wildcard      bool   `json:"wildcard,omitempty"`
+	Exclude       bool   `json:"exclude,omitempty"`
+	DirectRouting bool   `json:"directRouting,omitempty"`
+	IPv6MCast     bool   `json:"ipv6Mcast,omitempty"`
+	// BondMaster marks that Name should resolve to its bond/team master
+	// (role=bond-master in the line format) before being added to the
+	// filter, so a file naming a slave still ends up matching the uplink.
+	BondMaster bool `json:"bondMaster,omitempty"`
+}
+
+// parseDynamicDeviceLine parses a single line of the non-JSON dynamic
+// device file format, e.g. "eth0 direct-routing=true", "eth+", or
+// "!eth1". Returns false if the line is blank or a comment.
+func parseDynamicDeviceLine(line string) (DynamicDeviceConfig, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return DynamicDeviceConfig{}, false
+	}
+
+	fields := strings.Fields(line)
+	spec := fields[0]
+
+	var cfg DynamicDeviceConfig
+	if strings.HasPrefix(spec, "!") {
+		cfg.Exclude = true
+		spec = strings.TrimPrefix(spec, "!")
+	}
+	if strings.HasSuffix(spec, "+") {
+		cfg.Wildcard = true
+		spec = strings.TrimSuffix(spec, "+")
+	}
+	cfg.Name = spec
+
+	for _, attr := range fields[1:] {
+		parts := strings.SplitN(attr, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "direct-routing":
+			cfg.DirectRouting = parts[1] == "true"
+		case "ipv6-mcast":
+			cfg.IPv6MCast = parts[1] == "true"
+		case "role":
+			cfg.BondMaster = parts[1] == "bond-master"
+		}
+	}
+
+	return cfg, true
+}
+
+// parseDynamicDeviceFile parses one file under DynamicDevicePath, either as
+// a single JSON object or as newline-separated entries.
+func parseDynamicDeviceFile(path string) ([]DynamicDeviceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(trimmed, "{") {
+		var cfg DynamicDeviceConfig
+		if err := json.Unmarshal([]byte(trimmed), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid JSON dynamic device file %s: %w", path, err)
+		}
+		return []DynamicDeviceConfig{cfg}, nil
+	}
+
+	var configs []DynamicDeviceConfig
+	for _, line := range strings.Split(trimmed, "\n") {
+		if cfg, ok := parseDynamicDeviceLine(line); ok {
+			configs = append(configs, cfg)
+		}
+	}
+	return configs, nil
+}
+
+// ReadDynamicDevices reads DynamicDevicePath and returns the parsed
+// configuration of each file in it.
+//
+// When option.Config.DynamicDevicesLegacy is set, it instead keeps the
+// original behavior of treating every filename in the directory as a
+// "name+" wildcard, regardless of the file's contents.
+func ReadDynamicDevices() ([]DynamicDeviceConfig, error) {
 
-	devices := make([]string, 0)
 	pathState, err := os.Stat(DynamicDevicePath)
 	if err != nil && os.IsNotExist(err) {
 		log.WithField("DynamicDevicePath", DynamicDevicePath).
 			Info("dynamic-device config directory is not found, skip")
-		return devices, nil
+		return nil, nil
 	}
 
 	if err != nil {
@@ -720,22 +1216,94 @@ func ReadDynamicDevices() ([]string, error) {
 	if !pathState.IsDir() {
 		log.WithField("DynamicDevicePath", DynamicDevicePath).
 			Info("dynamic-device config is not directory, skip")
-		return devices, nil
+		return nil, nil
 	}
 
 	files, err := os.ReadDir(DynamicDevicePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []DynamicDeviceConfig
 	for _, f := range files {
 		if f.IsDir() {
 			continue
 		}
-		devices = append(devices, fmt.Sprintf("%s+", f.Name()))
+
+		if option.Config.DynamicDevicesLegacy {
+			configs = append(configs, DynamicDeviceConfig{Name: f.Name(), Wildcard: true})
+			continue
+		}
+
+		parsed, err := parseDynamicDeviceFile(filepath.Join(DynamicDevicePath, f.Name()))
+		if err != nil {
+			log.WithError(err).WithField("file", f.Name()).
+				Warning("failed to parse dynamic-device file, skip")
+			continue
+		}
+		configs = append(configs, parsed...)
 	}
-	if len(devices) > 0 {
+
+	if len(configs) > 0 {
 		log.WithField("DynamicDevicePath", DynamicDevicePath).
-			WithField("dynamic-devices", devices).
+			WithField("dynamic-devices", configs).
 			Debug("read dynamic-device config success")
 	}
 
-	return devices, nil
+	return configs, nil
+}
+
+// watchDynamicDevicesDir watches DynamicDevicePath for changes and signals
+// the returned channel (coalesced, capacity 1) whenever it sees one, so
+// Listen can recompute devices without waiting for the fallback ticker. In
+// legacy mode, if DynamicDevicePath doesn't exist, or if the watcher cannot
+// be set up, the fallback ticker is the only signal and a nil channel is
+// returned; like ReadDynamicDevices, it never creates the directory.
+func (dm *DeviceManager) watchDynamicDevicesDir(done <-chan struct{}) (<-chan struct{}, error) {
+	if option.Config.DynamicDevicesLegacy {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(DynamicDevicePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create dynamic devices watcher: %w", err)
+	}
+	if err := watcher.Add(DynamicDevicePath); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("unable to watch %s: %w", DynamicDevicePath, err)
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-done:
+				return
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.WithError(err).Warn("dynamic devices watcher error")
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				log.WithField("event", ev).Debug("dynamic devices directory changed")
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
 
+	return events, nil
 }
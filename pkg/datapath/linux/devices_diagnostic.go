@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package linux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/option"
+)
+
+// DefaultDiagnosticSocketPath is where the diagnostic server listens when
+// --device-manager-diagnostic-port is unset. Operators can still reach it
+// without exposing a TCP port, e.g. via `curl --unix-socket`.
+const DefaultDiagnosticSocketPath = "/var/run/cilium/device-manager-diagnostic.sock"
+
+// linkDiagnostic is the JSON representation of a single netlink.Link for the
+// diagnostic server.
+type linkDiagnostic struct {
+	Index        int    `json:"index"`
+	Type         string `json:"type"`
+	HardwareAddr string `json:"hardwareAddr"`
+	MTU          int    `json:"mtu"`
+	Flags        string `json:"flags"`
+	MasterName   string `json:"masterName,omitempty"`
+}
+
+func newLinkDiagnostic(dm *DeviceManager, link netlink.Link) linkDiagnostic {
+	attrs := link.Attrs()
+	d := linkDiagnostic{
+		Index:        attrs.Index,
+		Type:         link.Type(),
+		HardwareAddr: attrs.HardwareAddr.String(),
+		MTU:          attrs.MTU,
+		Flags:        attrs.Flags.String(),
+	}
+	if master, ok := dm.masterOf(link); ok {
+		d.MasterName = master.Attrs().Name
+	}
+	return d
+}
+
+// deviceDiagnostic is the JSON representation of a single tracked device for
+// the /devices endpoint.
+type deviceDiagnostic struct {
+	Name          string          `json:"name"`
+	Reason        string          `json:"reason,omitempty"`
+	Link          *linkDiagnostic `json:"link,omitempty"`
+	LinkError     string          `json:"linkError,omitempty"`
+	Filters       []string        `json:"filters,omitempty"`
+	FiltersError  string          `json:"filtersError,omitempty"`
+	TCFiltersLost bool            `json:"tcFiltersLost"`
+}
+
+// deviceManagerDiagnostics is the full /devices response.
+type deviceManagerDiagnostics struct {
+	Devices                    []deviceDiagnostic `json:"devices"`
+	ConfiguredStaticAndDynamic []string           `json:"configuredStaticAndDynamic"`
+}
+
+// diagnosticHandler implements http.Handler for the DeviceManager diagnostic
+// endpoints: GET /devices, GET /devices/{name}/filters, GET /devices/events
+// and POST /devices/redetect.
+type diagnosticHandler struct {
+	dm *DeviceManager
+}
+
+func (h *diagnosticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/devices":
+		h.listDevices(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == "/devices/events":
+		h.listEvents(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/devices/redetect":
+		h.redetect(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/devices/") && strings.HasSuffix(r.URL.Path, "/filters"):
+		h.deviceFilters(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// buildDeviceDiagnostic reads the live netlink state for name. It takes
+// dm.Mutex for its entire body, not just the dm.reasons lookup: dm.handle is
+// a single shared netlink socket, and before the diagnostic server existed
+// the only goroutine ever issuing requests on it was Listen(), always under
+// the lock. Without this, concurrent HTTP requests (or a request racing a
+// Listen() resync) would issue concurrent reads on that one socket.
+func (h *diagnosticHandler) buildDeviceDiagnostic(name string) deviceDiagnostic {
+	h.dm.Lock()
+	defer h.dm.Unlock()
+
+	d := deviceDiagnostic{Name: name, Reason: h.dm.reasons[name]}
+
+	link, err := h.dm.handle.LinkByName(name)
+	if err != nil {
+		d.LinkError = err.Error()
+		return d
+	}
+	linkInfo := newLinkDiagnostic(h.dm, link)
+	d.Link = &linkInfo
+
+	probeLinks, err := h.dm.devicesToProbe(name)
+	if err != nil {
+		d.FiltersError = err.Error()
+		return d
+	}
+	for _, pl := range probeLinks {
+		filters, err := h.dm.tcFilters(pl)
+		if err != nil {
+			d.FiltersError = err.Error()
+			continue
+		}
+		for _, f := range filters {
+			d.Filters = append(d.Filters, fmt.Sprintf("%s@%s", f.Name, pl.Attrs().Name))
+		}
+	}
+	d.TCFiltersLost = len(d.Filters) == 0
+
+	return d
+}
+
+func (h *diagnosticHandler) listDevices(w http.ResponseWriter, r *http.Request) {
+	h.dm.Lock()
+	names := h.dm.getDeviceList()
+	configuredStaticAndDynamic := h.dm.staticAndDynamicDeviceFilter()
+	h.dm.Unlock()
+
+	resp := deviceManagerDiagnostics{
+		Devices:                    make([]deviceDiagnostic, 0, len(names)),
+		ConfiguredStaticAndDynamic: []string(configuredStaticAndDynamic),
+	}
+	for _, name := range names {
+		resp.Devices = append(resp.Devices, h.buildDeviceDiagnostic(name))
+	}
+
+	writeJSON(w, resp)
+}
+
+func (h *diagnosticHandler) deviceFilters(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/devices/"), "/filters")
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, h.buildDeviceDiagnostic(name))
+}
+
+func (h *diagnosticHandler) listEvents(w http.ResponseWriter, r *http.Request) {
+	h.dm.Lock()
+	events := make([]deviceChangeEvent, len(h.dm.events))
+	copy(events, h.dm.events)
+	h.dm.Unlock()
+
+	writeJSON(w, events)
+}
+
+// redetect forces an immediate resync instead of calling Detect() directly,
+// so a manual redetect goes through the same recordEvent/devicesChan path a
+// netlink-triggered one does and downstream reloaders learn about it rather
+// than dm.devices silently diverging from their view of the current set.
+func (h *diagnosticHandler) redetect(w http.ResponseWriter, r *http.Request) {
+	h.dm.Lock()
+	changed, changedFamilies := h.dm.resync(h.dm.l3DevOK)
+	devices := h.dm.getDeviceList()
+	if changed {
+		h.dm.recordEvent(devices, changedFamilies)
+	}
+	ch := h.dm.devicesChan
+	h.dm.Unlock()
+
+	if changed {
+		log.WithField(logfields.Devices, devices).Info("Devices changed")
+		if ch != nil {
+			select {
+			case ch <- devices:
+			default:
+				log.Warning("device manager diagnostic: redetect notification dropped, devicesChan consumer is behind")
+			}
+		}
+	}
+
+	writeJSON(w, devices)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Warn("device manager diagnostic: failed to encode response")
+	}
+}
+
+// ServeDiagnostics starts the opt-in DeviceManager diagnostic HTTP server
+// and blocks serving requests until ctx is cancelled. By default it listens
+// on DefaultDiagnosticSocketPath; set
+// --device-manager-diagnostic-port to instead bind a localhost TCP port,
+// e.g. for use from outside the host's mount namespace.
+func (dm *DeviceManager) ServeDiagnostics(ctx context.Context) error {
+	var listener net.Listener
+	var err error
+
+	if option.Config.DeviceManagerDiagnosticPort > 0 {
+		addr := fmt.Sprintf("127.0.0.1:%d", option.Config.DeviceManagerDiagnosticPort)
+		listener, err = net.Listen("tcp", addr)
+	} else {
+		_ = os.Remove(DefaultDiagnosticSocketPath)
+		listener, err = net.Listen("unix", DefaultDiagnosticSocketPath)
+	}
+	if err != nil {
+		return fmt.Errorf("device manager diagnostic server failed to listen: %w", err)
+	}
+
+	server := &http.Server{Handler: &diagnosticHandler{dm: dm}}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.WithField("addr", listener.Addr()).Info("Serving device manager diagnostics")
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package linux
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticHandler_ListEvents(t *testing.T) {
+	dm := &DeviceManager{}
+	dm.recordEvent([]string{"eth0"}, nil)
+	dm.recordEvent([]string{"eth0", "eth1"}, []string{"ipv4"})
+
+	handler := &diagnosticHandler{dm: dm}
+
+	req := httptest.NewRequest("GET", "/devices/events", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, 200, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var events []deviceChangeEvent
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &events))
+	require.Len(t, events, 2)
+	require.Equal(t, []string{"eth0"}, events[0].Devices)
+	require.Empty(t, events[0].ChangedFamilies)
+	require.Equal(t, []string{"eth0", "eth1"}, events[1].Devices)
+	require.Equal(t, []string{"ipv4"}, events[1].ChangedFamilies)
+}
+
+func TestDiagnosticHandler_NotFound(t *testing.T) {
+	dm := &DeviceManager{}
+	handler := &diagnosticHandler{dm: dm}
+
+	req := httptest.NewRequest("GET", "/unknown", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, 404, rec.Code)
+}
+
+func TestWriteJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSON(rec, map[string]string{"hello": "world"})
+
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "world", body["hello"])
+}
+
+// TestDiagnosticHandler_DeviceFilters exercises the /devices/{name}/filters
+// route end to end against a real netlink handle, since buildDeviceDiagnostic
+// takes dm.Mutex around live netlink reads and can't be driven with a nil
+// handle.
+func TestDiagnosticHandler_DeviceFilters(t *testing.T) {
+	ns, dm := setupBondedNetns(t)
+	defer ns.Close()
+
+	handler := &diagnosticHandler{dm: dm}
+
+	req := httptest.NewRequest("GET", "/devices/bond0/filters", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, 200, rec.Code)
+
+	var d deviceDiagnostic
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &d))
+	require.Equal(t, "bond0", d.Name)
+	require.Empty(t, d.LinkError)
+	require.NotNil(t, d.Link)
+	require.True(t, d.TCFiltersLost)
+}
+
+// TestDiagnosticHandler_Redetect exercises POST /devices/redetect against a
+// real netlink handle with no devices configured, so resync finds nothing
+// changed and the endpoint still returns the (empty) device list as JSON
+// without panicking on a nil devicesChan.
+func TestDiagnosticHandler_Redetect(t *testing.T) {
+	ns, dm := setupBondedNetns(t)
+	defer ns.Close()
+
+	handler := &diagnosticHandler{dm: dm}
+
+	req := httptest.NewRequest("POST", "/devices/redetect", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, 200, rec.Code)
+
+	var devices []string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &devices))
+	require.Empty(t, devices)
+	require.Empty(t, dm.events)
+}
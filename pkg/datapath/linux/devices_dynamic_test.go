@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package linux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDynamicDeviceLine(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		want DynamicDeviceConfig
+	}{
+		{line: "", ok: false},
+		{line: "   ", ok: false},
+		{line: "# comment", ok: false},
+		{line: "eth0", ok: true, want: DynamicDeviceConfig{Name: "eth0"}},
+		{line: "eth+", ok: true, want: DynamicDeviceConfig{Name: "eth", Wildcard: true}},
+		{line: "!eth1", ok: true, want: DynamicDeviceConfig{Name: "eth1", Exclude: true}},
+		{
+			line: "eth0 direct-routing=true ipv6-mcast=true",
+			ok:   true,
+			want: DynamicDeviceConfig{Name: "eth0", DirectRouting: true, IPv6MCast: true},
+		},
+		{
+			line: "eth0 role=bond-master",
+			ok:   true,
+			want: DynamicDeviceConfig{Name: "eth0", BondMaster: true},
+		},
+		{
+			line: "eth0 unknown-attr=true",
+			ok:   true,
+			want: DynamicDeviceConfig{Name: "eth0"},
+		},
+	}
+
+	for _, tt := range tests {
+		cfg, ok := parseDynamicDeviceLine(tt.line)
+		require.Equal(t, tt.ok, ok, "line %q", tt.line)
+		if tt.ok {
+			require.Equal(t, tt.want, cfg, "line %q", tt.line)
+		}
+	}
+}
+
+func TestParseDynamicDeviceFile(t *testing.T) {
+	dir := t.TempDir()
+
+	linesPath := filepath.Join(dir, "lines")
+	require.NoError(t, os.WriteFile(linesPath, []byte("eth0\n# comment\n\neth+\n!eth1\n"), 0644))
+
+	configs, err := parseDynamicDeviceFile(linesPath)
+	require.NoError(t, err)
+	require.Equal(t, []DynamicDeviceConfig{
+		{Name: "eth0"},
+		{Name: "eth", Wildcard: true},
+		{Name: "eth1", Exclude: true},
+	}, configs)
+
+	jsonPath := filepath.Join(dir, "json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"name":"eth0","directRouting":true}`), 0644))
+
+	configs, err = parseDynamicDeviceFile(jsonPath)
+	require.NoError(t, err)
+	require.Equal(t, []DynamicDeviceConfig{{Name: "eth0", DirectRouting: true}}, configs)
+
+	emptyPath := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(emptyPath, []byte("  \n"), 0644))
+
+	configs, err = parseDynamicDeviceFile(emptyPath)
+	require.NoError(t, err)
+	require.Nil(t, configs)
+
+	invalidJSONPath := filepath.Join(dir, "invalid")
+	require.NoError(t, os.WriteFile(invalidJSONPath, []byte("{not json"), 0644))
+
+	_, err = parseDynamicDeviceFile(invalidJSONPath)
+	require.Error(t, err)
+}
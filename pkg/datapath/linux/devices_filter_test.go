@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package linux
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceFilterMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		lst  deviceFilter
+		dev  string
+		want bool
+	}{
+		{name: "empty filter matches everything", lst: nil, dev: "eth0", want: true},
+		{name: "exact match", lst: deviceFilter{"eth0"}, dev: "eth0", want: true},
+		{name: "exact mismatch", lst: deviceFilter{"eth0"}, dev: "eth1", want: false},
+		{name: "excluded device", lst: deviceFilter{"!eth1"}, dev: "eth1", want: false},
+		{
+			name: "exclude-only filter still matches devices it doesn't name",
+			lst:  deviceFilter{"!eth1"},
+			dev:  "eth0",
+			want: true,
+		},
+		{
+			name: "exclude-only filter with multiple excludes still matches others",
+			lst:  deviceFilter{"!eth1", "!eth2"},
+			dev:  "eth0",
+			want: true,
+		},
+		{
+			name: "exclude wins over a positive entry for the same device",
+			lst:  deviceFilter{"eth1", "!eth1"},
+			dev:  "eth1",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.lst.match(tt.dev, nil))
+		})
+	}
+}
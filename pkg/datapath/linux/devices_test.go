@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package linux
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+
+	"github.com/cilium/cilium/pkg/testutils"
+)
+
+// setupBondedNetns creates a network namespace containing a bond master
+// ("bond0") with two dummy slaves ("dummy0", "dummy1") and returns it along
+// with a DeviceManager bound to it. The caller must close the returned
+// netns.NsHandle.
+func setupBondedNetns(t *testing.T) (netns.NsHandle, *DeviceManager) {
+	testutils.PrivilegedTest(t)
+
+	ns, err := netns.New()
+	require.NoError(t, err)
+
+	handle, err := netlink.NewHandleAt(ns)
+	require.NoError(t, err)
+
+	bond := &netlink.Bond{
+		LinkAttrs: netlink.LinkAttrs{Name: "bond0"},
+		Mode:      netlink.BOND_MODE_ACTIVE_BACKUP,
+	}
+	require.NoError(t, handle.LinkAdd(bond))
+
+	for _, name := range []string{"dummy0", "dummy1"} {
+		dummy := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name}}
+		require.NoError(t, handle.LinkAdd(dummy))
+		require.NoError(t, handle.LinkSetMaster(dummy, bond))
+		require.NoError(t, handle.LinkSetUp(dummy))
+	}
+	require.NoError(t, handle.LinkSetUp(bond))
+
+	dm, err := NewDeviceManagerAt(ns)
+	require.NoError(t, err)
+
+	return ns, dm
+}
+
+func TestIsViableDevice_BondSlaveResolvesToMaster(t *testing.T) {
+	ns, dm := setupBondedNetns(t)
+	defer ns.Close()
+
+	slave, err := dm.handle.LinkByName("dummy0")
+	require.NoError(t, err)
+
+	name, viable := dm.isViableDevice(true, false, slave)
+	require.True(t, viable)
+	require.Equal(t, "bond0", name)
+}
+
+func TestExpandDeviceWildcards_BondSlaveResolvesToMaster(t *testing.T) {
+	ns, dm := setupBondedNetns(t)
+	defer ns.Close()
+
+	// checkDeviceWithIP must resolve "bond0" through dm.handle, not the
+	// default network namespace, or this wildcard expansion would find no
+	// devices at all since "bond0" only exists in ns. checkDeviceWithIP also
+	// requires an address of each enabled family, so give bond0 one of each.
+	bond, err := dm.handle.LinkByName("bond0")
+	require.NoError(t, err)
+	for _, cidr := range []string{"192.168.10.1/24", "fd00:10::1/64"} {
+		addr, err := netlink.ParseAddr(cidr)
+		require.NoError(t, err)
+		require.NoError(t, dm.handle.AddrAdd(bond, addr))
+	}
+
+	devices, err := dm.expandDeviceWildcards([]string{"dummy+"}, "devices")
+	require.NoError(t, err)
+	require.Equal(t, []string{"bond0"}, devices)
+}
+
+func TestResolveBondSlaves(t *testing.T) {
+	ns, dm := setupBondedNetns(t)
+	defer ns.Close()
+
+	master, err := dm.handle.LinkByName("bond0")
+	require.NoError(t, err)
+
+	slaves, err := dm.resolveBondSlaves(master)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(slaves))
+	for _, s := range slaves {
+		names = append(names, s.Attrs().Name)
+	}
+	require.ElementsMatch(t, []string{"dummy0", "dummy1"}, names)
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package node tracks address information about the local node.
+//
+// GetK8sNodeIP/SetK8sNodeIP and GetMasqIPv4AddrsWithDevices/
+// SetMasqIPv4AddrsWithDevices (consumed by pkg/datapath/linux's device
+// detection) are long-standing parts of this package and are not
+// reproduced here. This file only adds the IPv6 counterpart of the masq
+// address accessors, which checkStaticDevices now also needs to detect an
+// IPv6 address changing underneath Cilium.
+package node
+
+import (
+	"net"
+	"sync"
+)
+
+var (
+	masqIPv6Mutex         sync.RWMutex
+	masqIPv6AddrsByDevice = map[string]net.IP{}
+)
+
+// GetMasqIPv6AddrsWithDevices returns a copy of the last known IPv6
+// masquerade address per device.
+func GetMasqIPv6AddrsWithDevices() map[string]net.IP {
+	masqIPv6Mutex.RLock()
+	defer masqIPv6Mutex.RUnlock()
+	return copyAddrsByDevice(masqIPv6AddrsByDevice)
+}
+
+// SetMasqIPv6AddrsWithDevices replaces the tracked IPv6 masquerade address
+// per device.
+func SetMasqIPv6AddrsWithDevices(addrs map[string]net.IP) {
+	masqIPv6Mutex.Lock()
+	defer masqIPv6Mutex.Unlock()
+	masqIPv6AddrsByDevice = copyAddrsByDevice(addrs)
+}
+
+func copyAddrsByDevice(addrs map[string]net.IP) map[string]net.IP {
+	out := make(map[string]net.IP, len(addrs))
+	for k, v := range addrs {
+		out[k] = v
+	}
+	return out
+}
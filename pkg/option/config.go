@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package option holds flags and DaemonConfig fields consumed by
+// pkg/datapath/linux's device detection.
+//
+// DaemonConfig, the Config singleton, GetDevices/SetDevices/
+// DirectRoutingDeviceRequired and the Flags/Populate registration for
+// Devices/DirectRoutingDevice/IPv6MCastDevice are long-standing parts of
+// this package and are not reproduced here. This file only declares the
+// flag names and BondSlaveAttach values introduced by pkg/datapath/linux's
+// bond-attach, diagnostic server and dynamic-devices-legacy support; the
+// matching DaemonConfig fields, flag registrations and Populate reads are
+// not in this file and are listed here for reference:
+//
+//	DaemonConfig gains:
+//	  BondSlaveAttach                string // default BondSlaveAttachMaster
+//	  DeviceManagerDiagnosticPort    int
+//	  EnableDeviceManagerDiagnostics bool
+//	  DynamicDevicesLegacy           bool
+//
+//	Flags registers:
+//	  flags.String(BondSlaveAttach, BondSlaveAttachMaster, "Attach BPF programs to bond/team master, slaves, or both (master, slaves, both)")
+//	  flags.Int(DeviceManagerDiagnosticPort, 0, "TCP port for the device manager diagnostic server; 0 listens on a unix socket instead")
+//	  flags.Bool(EnableDeviceManagerDiagnostics, false, "Enable the device manager diagnostic HTTP server")
+//	  flags.Bool(DynamicDevicesLegacy, false, "Treat every file under the dynamic-devices directory as a name+ wildcard, ignoring its contents")
+//
+//	Populate reads:
+//	  c.BondSlaveAttach = vp.GetString(BondSlaveAttach)
+//	  c.DeviceManagerDiagnosticPort = vp.GetInt(DeviceManagerDiagnosticPort)
+//	  c.EnableDeviceManagerDiagnostics = vp.GetBool(EnableDeviceManagerDiagnostics)
+//	  c.DynamicDevicesLegacy = vp.GetBool(DynamicDevicesLegacy)
+package option
+
+const (
+	// BondSlaveAttach is the flag name controlling whether BPF programs are
+	// attached to a bond/team master, its slaves, or both.
+	BondSlaveAttach = "bond-slave-attach"
+
+	// DeviceManagerDiagnosticPort is the flag name for the TCP port the
+	// DeviceManager diagnostic server listens on. Leave unset (0) to listen
+	// on DefaultDiagnosticSocketPath instead.
+	DeviceManagerDiagnosticPort = "device-manager-diagnostic-port"
+
+	// EnableDeviceManagerDiagnostics is the flag name that opts into running
+	// the DeviceManager diagnostic server at all. It is off by default.
+	EnableDeviceManagerDiagnostics = "enable-device-manager-diagnostics"
+
+	// DynamicDevicesLegacy is the flag name that reverts ReadDynamicDevices
+	// to treating every filename under DynamicDevicePath as a "name+"
+	// wildcard, ignoring file contents.
+	DynamicDevicesLegacy = "dynamic-devices-legacy"
+)
+
+// Values for BondSlaveAttach.
+const (
+	// BondSlaveAttachMaster attaches BPF programs only to the bond/team
+	// master. This is the default.
+	BondSlaveAttachMaster = "master"
+	// BondSlaveAttachSlaves attaches BPF programs only to the bond/team
+	// slaves, e.g. for XDP which cannot be loaded on the master itself.
+	BondSlaveAttachSlaves = "slaves"
+	// BondSlaveAttachBoth attaches BPF programs to both the master and its
+	// slaves.
+	BondSlaveAttachBoth = "both"
+)